@@ -16,14 +16,26 @@
 //   error != nil  | 1 (failure)
 //   panic         | 1 (failure)
 //
+// Cleanup ordering
+//
+// Bind no longer runs cleanups sequentially in reverse registration order like a defer
+// stack: it registers at DefaultPriority, and cleanups sharing a priority run concurrently
+// with each other. Use BindPriority with distinct priorities for cleanups that must run in
+// a specific order relative to one another. See Bind's doc comment for details.
+//
 package closer
 
 import (
+	"context"
+	"fmt"
 	"log"
 	"os"
 	"os/signal"
+	"runtime/debug"
+	"sort"
 	"sync"
 	"syscall"
+	"time"
 )
 
 var (
@@ -31,12 +43,17 @@ var (
 	// these signals will terminate the app without executing the code in defer blocks.
 	DebugSignalSet = []os.Signal{
 		syscall.SIGINT,
-		syscall.SIGHUP,
 		syscall.SIGTERM,
 	}
 	// DefaultSignalSet will have syscall.SIGABRT that should be
 	// opted out if user wants to debug the stacktrace.
 	DefaultSignalSet = append(DebugSignalSet, syscall.SIGABRT)
+	// DefaultReloadSignalSet is a predefined list of signals that trigger the handlers
+	// registered via OnReload instead of a shutdown, following the common daemon
+	// convention of SIGHUP meaning "reload your config".
+	DefaultReloadSignalSet = []os.Signal{
+		syscall.SIGHUP,
+	}
 )
 
 var (
@@ -46,87 +63,338 @@ var (
 	ExitCodeErr = 1
 	// ExitSignals is the active list of signals to watch for.
 	ExitSignals = DefaultSignalSet
+	// ReloadSignals is the active list of signals that trigger OnReload handlers.
+	ReloadSignals = DefaultReloadSignalSet
 )
 
-// Config should be used with Init function to override the defaults.
+// Config should be used with Init function (or New) to override the defaults.
 type Config struct {
 	ExitCodeOK  int
 	ExitCodeErr int
 	ExitSignals []os.Signal
+	// CleanupTimeout bounds how long the bound cleanups are allowed to run for once
+	// a close request is observed. Zero (the default) means no deadline, cleanups
+	// run to completion just like before. If the deadline elapses, the remaining
+	// cleanups are skipped and the app exits with ExitCodeErr.
+	CleanupTimeout time.Duration
+	// ForceExitOnSecondSignal, when true, makes closer call ExitFunc(ExitCodeErr) right
+	// away if a second matching signal arrives while the bound cleanups are still
+	// running, instead of waiting for them (or the CleanupTimeout) to finish.
+	ForceExitOnSecondSignal bool
+	// OnForceExit, if set, is called just before the forced exit triggered by
+	// ForceExitOnSecondSignal, with the signal that caused it. Useful for apps that
+	// want to ship telemetry about an unclean shutdown.
+	OnForceExit func(sig os.Signal)
+	// ReloadSignals is the set of signals that trigger OnReload handlers instead of
+	// tearing the app down.
+	ReloadSignals []os.Signal
+	// CrashReporter, if set, is called with the recovered panic and its stack trace before
+	// the bound cleanups run, whenever Close or Checked recovers one. This gives apps a
+	// single hook to ship crashes to a Sentry/rollbar-style backend without wrapping main.
+	CrashReporter func(info PanicInfo)
+	// ExitFunc is called to actually terminate the process once cleanups are done, in place
+	// of os.Exit. Defaults to os.Exit. Tests can substitute a recorder so a Closer can be
+	// exercised without killing the test binary.
+	ExitFunc func(code int)
+}
+
+// PanicInfo describes a panic recovered by Close or Checked. There's no Signal field: OS
+// signals are handled entirely through signalChan, never via a recovered panic, so a panic
+// reaching CrashReporter is always a plain one from application code.
+type PanicInfo struct {
+	// Value is whatever was passed to panic().
+	Value interface{}
+	// Stack is the stack trace of the panicking goroutine, as captured by runtime/debug.Stack.
+	Stack []byte
+}
+
+// cleanupFunc is the internal representation of a bound cleanup: Bind wraps a plain
+// func() into one of these, BindContext stores its argument directly.
+type cleanupFunc func(ctx context.Context) error
+
+// DefaultPriority is the priority plain Bind/BindContext/BindGroup cleanups run at.
+// Cleanups bound at the same priority run concurrently with each other, so this is also
+// the tier that determines Bind's behavior: bind more than one cleanup at DefaultPriority
+// and they'll race each other, same as any other shared priority. Use BindPriority to
+// order cleanups into separate tiers.
+const DefaultPriority = 0
+
+// cleanupEntry wraps a bound cleanup together with a disabled flag, so the CancelFunc
+// returned by Bind/BindContext can detach it in O(1) without reshuffling the slice.
+type cleanupEntry struct {
+	fn       cleanupFunc
+	disabled bool
+	priority int
+	group    string
+}
+
+// label identifies the entry for diagnostics, e.g. the force-exit log line.
+func (e *cleanupEntry) label() string {
+	if e.group != "" {
+		return e.group
+	}
+	return fmt.Sprintf("priority %d cleanup", e.priority)
 }
 
-var c = newCloser()
+// CancelFunc detaches a previously bound cleanup, so it won't be run on shutdown. Calling
+// it more than once is a no-op.
+type CancelFunc func()
 
-type closer struct {
-	codeOK     int
-	codeErr    int
-	signals    []os.Signal
-	sem        sync.Mutex
-	cleanups   []func()
-	errChan    chan struct{}
-	doneChan   chan struct{}
-	signalChan chan os.Signal
-	closeChan  chan struct{}
-	holdChan   chan struct{}
+// Closer watches for a close request (an OS signal, Close, or an error/panic from Checked)
+// and runs the bound cleanups before the process exits. The package-level functions (Bind,
+// Close, Checked, Hold, ...) are thin wrappers around a default instance; construct your own
+// with New when you need an isolated instance, e.g. in tests.
+type Closer struct {
+	codeOK                  int
+	codeErr                 int
+	signals                 []os.Signal
+	reloadSignals           []os.Signal
+	cleanupTimeout          time.Duration
+	forceExitOnSecondSignal bool
+	onForceExit             func(sig os.Signal)
+	crashReporter           func(info PanicInfo)
+	exitFunc                func(code int)
+	sem                     sync.Mutex
+	cleanups                []*cleanupEntry
+	reloadHandlers          []func() error
+	errChan                 chan struct{}
+	doneChan                chan struct{}
+	signalChan              chan os.Signal
+	reloadChan              chan os.Signal
+	closeChan               chan struct{}
+	holdChan                chan struct{}
 	//
 	cancelWaitChan chan struct{}
+	//
+	ctx    context.Context
+	cancel context.CancelFunc
 }
 
-func newCloser() *closer {
-	c := &closer{
-		codeOK:  ExitCodeOK,
-		codeErr: ExitCodeErr,
-		signals: ExitSignals,
+// New creates a Closer from cfg and starts watching for a close request right away. Unlike
+// Init, cfg is used as given: a zero Config gets you exit code 0 for both success and
+// failure and no signals watched at all, which is rarely what you want outside of a test
+// that only cares about Bind/Close/Checked. Pass ExitFunc to intercept the final exit call,
+// e.g. to assert on it instead of killing the test binary.
+func New(cfg Config) *Closer {
+	if cfg.ExitFunc == nil {
+		cfg.ExitFunc = os.Exit
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &Closer{
+		codeOK:                  cfg.ExitCodeOK,
+		codeErr:                 cfg.ExitCodeErr,
+		signals:                 cfg.ExitSignals,
+		reloadSignals:           cfg.ReloadSignals,
+		cleanupTimeout:          cfg.CleanupTimeout,
+		forceExitOnSecondSignal: cfg.ForceExitOnSecondSignal,
+		onForceExit:             cfg.OnForceExit,
+		crashReporter:           cfg.CrashReporter,
+		exitFunc:                cfg.ExitFunc,
 		//
 		errChan:    make(chan struct{}),
 		doneChan:   make(chan struct{}),
 		signalChan: make(chan os.Signal, 1),
+		reloadChan: make(chan os.Signal, 1),
 		closeChan:  make(chan struct{}),
 		holdChan:   make(chan struct{}),
 		//
 		cancelWaitChan: make(chan struct{}),
+		//
+		ctx:    ctx,
+		cancel: cancel,
 	}
 
 	signal.Notify(c.signalChan, c.signals...)
+	signal.Notify(c.reloadChan, c.reloadSignals...)
 
 	// start waiting
 	go c.wait()
 	return c
 }
 
-func (c *closer) wait() {
+// c is the package-level default Closer instance, backing the top-level functions below.
+var c = New(Config{
+	ExitCodeOK:    ExitCodeOK,
+	ExitCodeErr:   ExitCodeErr,
+	ExitSignals:   ExitSignals,
+	ReloadSignals: ReloadSignals,
+})
+
+func (c *Closer) wait() {
 	exitCode := c.codeOK
 
-	// wait for a close request
-	select {
-	case <-c.cancelWaitChan:
-		return
-	case <-c.signalChan:
-	case <-c.closeChan:
-		break
-	case <-c.errChan:
-		exitCode = c.codeErr
+	// wait for a close request, dispatching reload signals as they arrive instead of
+	// treating them as terminal
+waitLoop:
+	for {
+		select {
+		case <-c.cancelWaitChan:
+			return
+		case sig := <-c.reloadChan:
+			// run handlers in their own goroutine: runReload can be arbitrarily slow
+			// (e.g. re-reading a config file), and waitLoop must keep selecting on
+			// signalChan/closeChan/errChan so a concurrent shutdown isn't blocked on it
+			go c.runReload(sig)
+		case <-c.signalChan:
+			break waitLoop
+		case <-c.closeChan:
+			break waitLoop
+		case <-c.errChan:
+			exitCode = c.codeErr
+			break waitLoop
+		}
+	}
+
+	// let anything watching Context() start winding down right away,
+	// concurrently with the cleanups below
+	c.cancel()
+
+	// stateMu guards exitCode and running, both written by the cleanup goroutine below and
+	// read from wait()'s own goroutine (the deferred exit, and the force-exit branch).
+	var stateMu sync.Mutex
+	// exitOnce makes sure ExitFunc is called exactly once, even though both the deferred
+	// exit below and the force-exit branch can reach it.
+	var exitOnce sync.Once
+	doExit := func(code int) {
+		exitOnce.Do(func() {
+			c.exitFunc(code)
+		})
 	}
 
 	// ensure we'll exit
-	defer os.Exit(exitCode)
+	defer func() {
+		stateMu.Lock()
+		code := exitCode
+		stateMu.Unlock()
+		doExit(code)
+	}()
 
 	c.sem.Lock()
 	defer c.sem.Unlock()
-	for _, fn := range c.cleanups {
-		fn()
+
+	ctx := context.Background()
+	if c.cleanupTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.cleanupTimeout)
+		defer cancel()
+	}
+
+	// running names the cleanups currently executing in the active priority tier, so a
+	// forced exit can report what it interrupted. Only the goroutine below writes it.
+	var running []string
+
+	cleanupsDone := make(chan struct{})
+	go func() {
+		defer close(cleanupsDone)
+
+		tiers := make(map[int][]*cleanupEntry, len(c.cleanups))
+		var priorities []int
+		for _, entry := range c.cleanups {
+			if _, ok := tiers[entry.priority]; !ok {
+				priorities = append(priorities, entry.priority)
+			}
+			tiers[entry.priority] = append(tiers[entry.priority], entry)
+		}
+		sort.Sort(sort.Reverse(sort.IntSlice(priorities)))
+
+	tierLoop:
+		for _, p := range priorities {
+			select {
+			case <-ctx.Done():
+				// deadline exceeded, skip whatever tiers are left
+				stateMu.Lock()
+				exitCode = c.codeErr
+				stateMu.Unlock()
+				break tierLoop
+			default:
+			}
+
+			stateMu.Lock()
+			running = running[:0]
+			stateMu.Unlock()
+
+			var wg sync.WaitGroup
+			for _, entry := range tiers[p] {
+				if entry.disabled {
+					continue
+				}
+				wg.Add(1)
+				go func(entry *cleanupEntry) {
+					defer wg.Done()
+					stateMu.Lock()
+					running = append(running, entry.label())
+					stateMu.Unlock()
+					entry.fn(ctx)
+				}(entry)
+			}
+			tierDone := make(chan struct{})
+			go func() {
+				wg.Wait()
+				close(tierDone)
+			}()
+
+			select {
+			case <-tierDone:
+			case <-ctx.Done():
+				// deadline exceeded mid-tier: stop waiting on cleanups that ignored ctx
+				// (e.g. plain Bind/BindPriority/BindGroup callbacks); their goroutines
+				// are abandoned and may finish later, but shutdown must not wait on them
+				stateMu.Lock()
+				exitCode = c.codeErr
+				stateMu.Unlock()
+				break tierLoop
+			}
+		}
+	}()
+
+	// done closes both doneChan (so Close/Checked/closeErr stop waiting) and holdChan
+	// (so Hold returns), whether we got here by running cleanups to completion or by
+	// forcing an exit. With the real os.Exit this only matters for doneChan, since the
+	// process is already gone by the time we'd reach it; with a substituted ExitFunc
+	// that returns (the whole point of that option), both callers need to be released.
+	done := func() {
+		close(c.doneChan)
+		close(c.holdChan)
+	}
+
+	if !c.forceExitOnSecondSignal {
+		<-cleanupsDone
+		done()
+		return
+	}
+
+	// keep draining signalChan so a second signal can force-quit immediately,
+	// instead of waiting for cleanupsDone or the CleanupTimeout
+	for {
+		select {
+		case <-cleanupsDone:
+			done()
+			return
+		case sig := <-c.signalChan:
+			if c.onForceExit != nil {
+				c.onForceExit(sig)
+			}
+			stateMu.Lock()
+			log.Printf("closer: got a second signal (%v) while %v was still running, forcing exit", sig, running)
+			stateMu.Unlock()
+			doExit(c.codeErr)
+			// with the real os.Exit we never get here, but a substituted ExitFunc (tests)
+			// may return: the deferred doExit above becomes a no-op since ExitFunc already
+			// ran once, but Close/Checked/closeErr/Hold must still be released
+			done()
+			return
+		}
 	}
-	// done!
-	close(c.doneChan)
 }
 
 // Close sends a close request.
-// The app will be terminated by OS as soon as the first close request will be handled by closer, this
+// The app will be terminated as soon as the first close request will be handled by closer, this
 // function will return no sooner. The exit code will always be 0 (success).
-func Close() {
+func (c *Closer) Close() {
 	// check if there was a panic
 	if x := recover(); x != nil {
 		log.Printf("run time panic: %v", x)
+		c.reportPanic(x)
 		// close with an error
 		close(c.errChan)
 	} else {
@@ -136,46 +404,194 @@ func Close() {
 	<-c.doneChan
 }
 
-func (c *closer) closeErr() {
+// Close sends a close request to the default Closer. See (*Closer).Close.
+func Close() {
+	c.Close()
+}
+
+func (c *Closer) closeErr() {
 	close(c.errChan)
 	<-c.doneChan
 }
 
-// Init allows user to override the defaults (a set of OS signals to watch for, for example).
-func Init(cfg Config) {
+// reportPanic captures the current stack and forwards it to Config.CrashReporter, if one was
+// set. It must be called from inside the recover() that caught x, before the cleanups run.
+func (c *Closer) reportPanic(x interface{}) {
+	if c.crashReporter == nil {
+		return
+	}
+	c.crashReporter(PanicInfo{
+		Value: x,
+		Stack: debug.Stack(),
+	})
+}
+
+// runReload calls the registered reload handlers in the order they were bound. It doesn't
+// hold c.sem while they run, and waitLoop invokes it in its own goroutine, so a slow or
+// stuck handler can't wedge OnReload or a concurrent shutdown.
+func (c *Closer) runReload(sig os.Signal) {
+	c.sem.Lock()
+	handlers := make([]func() error, len(c.reloadHandlers))
+	copy(handlers, c.reloadHandlers)
+	c.sem.Unlock()
+
+	for _, fn := range handlers {
+		if err := fn(); err != nil {
+			log.Printf("closer: reload handler failed on %v: %v", sig, err)
+		}
+	}
+}
+
+// Init reconfigures the Closer in place (a set of OS signals to watch for, for example).
+func (c *Closer) Init(cfg Config) {
+	if cfg.ExitFunc == nil {
+		cfg.ExitFunc = os.Exit
+	}
 	c.sem.Lock()
 	signal.Stop(c.signalChan)
+	signal.Stop(c.reloadChan)
 	close(c.cancelWaitChan)
 	c.codeOK = cfg.ExitCodeOK
 	c.codeErr = cfg.ExitCodeErr
 	c.signals = cfg.ExitSignals
+	c.reloadSignals = cfg.ReloadSignals
+	c.cleanupTimeout = cfg.CleanupTimeout
+	c.forceExitOnSecondSignal = cfg.ForceExitOnSecondSignal
+	c.onForceExit = cfg.OnForceExit
+	c.crashReporter = cfg.CrashReporter
+	c.exitFunc = cfg.ExitFunc
+	c.ctx, c.cancel = context.WithCancel(context.Background())
 	signal.Notify(c.signalChan, c.signals...)
+	signal.Notify(c.reloadChan, c.reloadSignals...)
 	go c.wait()
 	c.sem.Unlock()
 }
 
+// Init allows user to override the defaults of the default Closer. See (*Closer).Init.
+func Init(cfg Config) {
+	c.Init(cfg)
+}
+
+// OnReload registers a handler to be called when SIGHUP (or any of Config.ReloadSignals) is
+// received, instead of the app being shut down. Handlers run in the order they were
+// registered; a returned error doesn't stop the remaining handlers, it is only logged.
+func (c *Closer) OnReload(handler func() error) {
+	c.sem.Lock()
+	c.reloadHandlers = append(c.reloadHandlers, handler)
+	c.sem.Unlock()
+}
+
+// OnReload registers a reload handler on the default Closer. See (*Closer).OnReload.
+func OnReload(handler func() error) {
+	c.OnReload(handler)
+}
+
+// Context returns a context.Context that gets canceled the moment a close request is
+// observed (a signal, Close or an error/panic from Checked) — before the bound cleanups
+// even start running. Long-running goroutines can select on it instead of binding a
+// dedicated cleanup just to learn that shutdown has begun.
+func (c *Closer) Context() context.Context {
+	return c.ctx
+}
+
+// Context returns the default Closer's context. See (*Closer).Context.
+func Context() context.Context {
+	return c.Context()
+}
+
 // Bind will register the cleanup function that will be called when closer will get a close request.
-// All the callbacks will be called in the reverse order they were bound, that's similar to how `defer` works.
-func Bind(cleanup func()) {
+// It runs at DefaultPriority; see BindPriority if you need this cleanup ordered relative to others.
+// The returned CancelFunc detaches the cleanup again, for subsystems that come and go before shutdown.
+//
+// Breaking change: Bind used to run every registered cleanup sequentially in reverse
+// registration order, like a defer stack. It no longer does: all plain Bind cleanups share
+// DefaultPriority, and cleanups within a priority tier run concurrently with each other, so
+// two plain Bind calls will now race instead of the second one waiting for the first. Code
+// relying on the old LIFO ordering between two Bind calls (e.g. an HTTP server that must
+// drain before the DB pool it talks to closes) must switch to BindPriority with distinct
+// priorities to get that ordering back.
+func (c *Closer) Bind(cleanup func()) CancelFunc {
+	return c.BindPriority(cleanup, DefaultPriority)
+}
+
+// Bind registers a cleanup on the default Closer. See (*Closer).Bind.
+func Bind(cleanup func()) CancelFunc {
+	return c.Bind(cleanup)
+}
+
+// BindContext is the context-aware variant of Bind: the cleanup receives a context that
+// carries the configured Config.CleanupTimeout deadline, so it can cut its work short
+// instead of risking being skipped altogether. Any error it returns is currently ignored,
+// it exists so future versions of closer can surface cleanup failures.
+func (c *Closer) BindContext(cleanup func(ctx context.Context) error) CancelFunc {
+	return c.bind(cleanup, DefaultPriority, "")
+}
+
+// BindContext registers a context-aware cleanup on the default Closer. See (*Closer).BindContext.
+func BindContext(cleanup func(ctx context.Context) error) CancelFunc {
+	return c.BindContext(cleanup)
+}
+
+// BindPriority registers a cleanup to run at the given priority. During shutdown, cleanups
+// run in descending priority order: the highest-priority tier runs first and must finish (or
+// hit the CleanupTimeout) before the next tier starts. Cleanups sharing a priority run
+// concurrently with each other, so order cleanups that depend on one another (e.g. an HTTP
+// server that must drain before the DB pool it talks to closes) with distinct priorities.
+func (c *Closer) BindPriority(cleanup func(), priority int) CancelFunc {
+	return c.bind(func(ctx context.Context) error {
+		cleanup()
+		return nil
+	}, priority, "")
+}
+
+// BindPriority registers a prioritized cleanup on the default Closer. See (*Closer).BindPriority.
+func BindPriority(cleanup func(), priority int) CancelFunc {
+	return c.BindPriority(cleanup, priority)
+}
+
+// BindGroup registers a cleanup at DefaultPriority under a named group. Cleanups in the same
+// group run concurrently with each other, just like same-priority cleanups do; the name only
+// exists to make shutdown logs (e.g. the force-exit message) identify what was running.
+func (c *Closer) BindGroup(name string, cleanup func()) CancelFunc {
+	return c.bind(func(ctx context.Context) error {
+		cleanup()
+		return nil
+	}, DefaultPriority, name)
+}
+
+// BindGroup registers a named cleanup on the default Closer. See (*Closer).BindGroup.
+func BindGroup(name string, cleanup func()) CancelFunc {
+	return c.BindGroup(name, cleanup)
+}
+
+func (c *Closer) bind(cleanup cleanupFunc, priority int, group string) CancelFunc {
 	c.sem.Lock()
+	entry := &cleanupEntry{fn: cleanup, priority: priority, group: group}
 	// store in the reverse order
-	s := make([]func(), 0, 1+len(c.cleanups))
-	s = append(s, cleanup)
+	s := make([]*cleanupEntry, 0, 1+len(c.cleanups))
+	s = append(s, entry)
 	c.cleanups = append(s, c.cleanups...)
 	c.sem.Unlock()
+
+	return func() {
+		c.sem.Lock()
+		entry.disabled = true
+		c.sem.Unlock()
+	}
 }
 
 // Checked runs the target function and checks for panics and errors it may yield. In case of panic or error, closer
 // will terminate the app with an error code, but either case it will call all the bound callbacks beforehand.
 // One can use this instead of `defer` if you need to care about errors and panics that always may happen.
 // This function optionally can emit log messages via standard `log` package.
-func Checked(target func() error, logging bool) {
+func (c *Closer) Checked(target func() error, logging bool) {
 	defer func() {
 		// check if there was a panic
 		if x := recover(); x != nil {
 			if logging {
 				log.Printf("run time panic: %v", x)
 			}
+			c.reportPanic(x)
 			// close with an error
 			c.closeErr()
 		}
@@ -189,8 +605,31 @@ func Checked(target func() error, logging bool) {
 	}
 }
 
+// Checked runs target under the default Closer. See (*Closer).Checked.
+func Checked(target func() error, logging bool) {
+	c.Checked(target, logging)
+}
+
+// Fatalln logs v, same as log.Println, and then triggers a close with ExitCodeErr, running the
+// bound cleanups first. Unlike log.Fatalln it doesn't exit immediately: the process only exits
+// once those cleanups (or the CleanupTimeout) are done.
+func (c *Closer) Fatalln(v ...interface{}) {
+	log.Println(v...)
+	c.closeErr()
+}
+
+// Fatalln logs v and closes the default Closer with an error. See (*Closer).Fatalln.
+func Fatalln(v ...interface{}) {
+	c.Fatalln(v...)
+}
+
 // Hold is a helper that may be used to hold the main from returning,
-// until the closer will do a proper exit via `os.Exit`.
-func Hold() {
+// until the closer will do a proper exit via ExitFunc.
+func (c *Closer) Hold() {
 	<-c.holdChan
 }
+
+// Hold blocks on the default Closer. See (*Closer).Hold.
+func Hold() {
+	c.Hold()
+}
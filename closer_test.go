@@ -0,0 +1,223 @@
+package closer
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBindOrderAndCancelFunc(t *testing.T) {
+	cl := New(Config{ExitFunc: func(int) {}})
+
+	var mu sync.Mutex
+	var order []int
+
+	cl.BindPriority(func() {
+		mu.Lock()
+		order = append(order, 1)
+		mu.Unlock()
+	}, 10)
+	cl.BindPriority(func() {
+		mu.Lock()
+		order = append(order, 2)
+		mu.Unlock()
+	}, 5)
+	cancel := cl.BindPriority(func() {
+		mu.Lock()
+		order = append(order, 3)
+		mu.Unlock()
+	}, 0)
+	cancel()
+
+	cl.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(order) != 2 || order[0] != 1 || order[1] != 2 {
+		t.Fatalf("expected tiers to run in descending priority order and the canceled cleanup to be skipped, got %v", order)
+	}
+}
+
+func TestBindSharesPriorityAndRunsConcurrently(t *testing.T) {
+	cl := New(Config{ExitFunc: func(int) {}})
+
+	const n = 5
+	release := make(chan struct{})
+	started := make(chan struct{}, n)
+	for i := 0; i < n; i++ {
+		cl.Bind(func() {
+			started <- struct{}{}
+			<-release
+		})
+	}
+
+	closeDone := make(chan struct{})
+	go func() {
+		cl.Close()
+		close(closeDone)
+	}()
+
+	// every plain Bind call shares DefaultPriority and must start concurrently: if Bind
+	// still ran cleanups sequentially (the old LIFO/defer-stack behavior), only the first
+	// would have started and this would time out waiting for the rest.
+	for i := 0; i < n; i++ {
+		select {
+		case <-started:
+		case <-time.After(time.Second):
+			t.Fatalf("only %d/%d plain Bind cleanups had started concurrently", i, n)
+		}
+	}
+
+	close(release)
+	select {
+	case <-closeDone:
+	case <-time.After(time.Second):
+		t.Fatal("Close() did not return after all cleanups finished")
+	}
+}
+
+func TestCleanupTimeoutSkipsSlowCleanups(t *testing.T) {
+	exited := make(chan int, 1)
+	cl := New(Config{
+		ExitCodeErr:    1,
+		CleanupTimeout: 100 * time.Millisecond,
+		ExitFunc: func(code int) {
+			select {
+			case exited <- code:
+			default:
+			}
+		},
+	})
+
+	cl.Bind(func() { time.Sleep(2 * time.Second) })
+
+	start := time.Now()
+	go cl.Close()
+
+	select {
+	case code := <-exited:
+		if code != 1 {
+			t.Fatalf("expected ExitCodeErr (1), got %d", code)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Fatalf("expected shutdown to stop waiting around the CleanupTimeout, took %v", elapsed)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("closer did not exit within 1s of a 100ms CleanupTimeout")
+	}
+}
+
+func TestSlowReloadHandlerDoesNotBlockShutdown(t *testing.T) {
+	exited := make(chan int, 1)
+	cl := New(Config{
+		ExitFunc: func(code int) {
+			select {
+			case exited <- code:
+			default:
+			}
+		},
+	})
+
+	handlerStarted := make(chan struct{})
+	cl.OnReload(func() error {
+		close(handlerStarted)
+		time.Sleep(2 * time.Second)
+		return nil
+	})
+
+	cl.reloadChan <- nil
+	<-handlerStarted
+
+	closeDone := make(chan struct{})
+	go func() {
+		cl.Close()
+		close(closeDone)
+	}()
+
+	select {
+	case <-closeDone:
+	case <-time.After(time.Second):
+		t.Fatal("Close() blocked on a reload handler that was still running")
+	}
+
+	select {
+	case <-exited:
+	case <-time.After(time.Second):
+		t.Fatal("ExitFunc was not called")
+	}
+}
+
+func TestHoldReturnsAfterNonTerminatingExitFunc(t *testing.T) {
+	exited := make(chan int, 1)
+	cl := New(Config{
+		ExitFunc: func(code int) {
+			// deliberately doesn't call os.Exit, like a test recorder would
+			select {
+			case exited <- code:
+			default:
+			}
+		},
+	})
+
+	holdDone := make(chan struct{})
+	go func() {
+		cl.Hold()
+		close(holdDone)
+	}()
+
+	go cl.Close()
+
+	select {
+	case <-exited:
+	case <-time.After(time.Second):
+		t.Fatal("ExitFunc was not called")
+	}
+
+	select {
+	case <-holdDone:
+	case <-time.After(time.Second):
+		t.Fatal("Hold() did not return after a non-terminating ExitFunc")
+	}
+}
+
+func TestForceExitOnSecondSignal(t *testing.T) {
+	exited := make(chan int, 1)
+	cl := New(Config{
+		ExitCodeErr:             7,
+		ForceExitOnSecondSignal: true,
+		ExitFunc: func(code int) {
+			select {
+			case exited <- code:
+			default:
+			}
+		},
+	})
+
+	cl.Bind(func() { time.Sleep(2 * time.Second) })
+
+	closeDone := make(chan struct{})
+	go func() {
+		cl.Close()
+		close(closeDone)
+	}()
+
+	// wait for the close request to be picked up and the stuck cleanup to start, then
+	// simulate a second signal forcing the exit
+	time.Sleep(50 * time.Millisecond)
+	cl.signalChan <- nil
+
+	select {
+	case code := <-exited:
+		if code != 7 {
+			t.Fatalf("expected ExitCodeErr (7), got %d", code)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("ExitFunc was not called on the second signal")
+	}
+
+	select {
+	case <-closeDone:
+	case <-time.After(time.Second):
+		t.Fatal("Close() hung after the forced exit")
+	}
+}